@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveWriter abstracts over the different on-disk output formats the
+// cloner can produce (plain directory, WARC, MHTML, single-file HTML).
+// processEntry and downloadResource write every fetched URL through a writer
+// so the crawling logic stays the same regardless of -format.
+type ArchiveWriter interface {
+	// WriteResource records a fetched URL's response headers and body.
+	// It returns the reference that should be used in place of the
+	// original href/src when rewriting the page that linked to it.
+	WriteResource(rawURL string, headers http.Header, body []byte) (ref string, err error)
+
+	// Close flushes and closes any underlying file. It is called once
+	// after the crawl finishes.
+	Close() error
+}
+
+// NewArchiveWriter builds the ArchiveWriter for the requested -format.
+// resources is shared with the rest of the crawler so that the path
+// dirWriter saves a resource to always matches the href/src already
+// rewritten into the page that links to it.
+func NewArchiveWriter(format, outputDir string, resources *ResourceMap) (ArchiveWriter, error) {
+	switch format {
+	case "", "dir":
+		return newDirWriter(outputDir, resources)
+	case "warc":
+		return newWarcWriter(outputDir)
+	case "mhtml":
+		return newMHTMLWriter(outputDir)
+	case "single-file":
+		return newSingleFileWriter(outputDir)
+	default:
+		return nil, fmt.Errorf("unknown -format %q (want dir, warc, mhtml, or single-file)", format)
+	}
+}
+
+// dirWriter reproduces the original behavior: every resource is written
+// as its own file under <outdir>/resources/<type>/<name>, except the
+// path is now resolved through the shared ResourceMap instead of
+// filepath.Base so two resources never collide.
+type dirWriter struct {
+	outputDir string
+	resources *ResourceMap
+}
+
+func newDirWriter(outputDir string, resources *ResourceMap) (*dirWriter, error) {
+	return &dirWriter{outputDir: outputDir, resources: resources}, nil
+}
+
+func (w *dirWriter) WriteResource(rawURL string, headers http.Header, body []byte) (string, error) {
+	resourceType := resourceTypeFromContentType(headers.Get("Content-Type"))
+	relPath := w.resources.PathFor(rawURL, resourceType)
+
+	outputPath := filepath.Join(w.outputDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(outputPath, body, 0644); err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(relPath), nil
+}
+
+func (w *dirWriter) Close() error { return nil }
+
+// resourceTypeFromContentType maps a response Content-Type to one of the
+// CSS/JS/IMG buckets the directory layout uses, defaulting to IMG for
+// anything else (fonts, binaries, etc).
+func resourceTypeFromContentType(contentType string) string {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "css"):
+		return CSS
+	case strings.Contains(ct, "javascript") || strings.Contains(ct, "ecmascript"):
+		return JS
+	default:
+		return IMG
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// mhtmlWriter builds a single multipart/related MIME document, matching
+// the layout browsers produce for "Save Page As... Webpage, Single File".
+// Every part (the HTML page itself plus every resource it references)
+// gets its own Content-Location header set to the original URL it was
+// fetched from.
+type mhtmlWriter struct {
+	file     *os.File
+	boundary string
+	wrote    bool
+}
+
+const mhtmlBoundary = "----=_NextPart_website_cloner"
+
+func newMHTMLWriter(outputDir string) (*mhtmlWriter, error) {
+	path := outputDir + ".mhtml"
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &mhtmlWriter{file: f, boundary: mhtmlBoundary}
+	if err := w.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *mhtmlWriter) writeHeader() error {
+	_, err := fmt.Fprintf(w.file,
+		"From: <Saved by website-cloner>\r\n"+
+			"Subject: Saved Webpage\r\n"+
+			"MIME-Version: 1.0\r\n"+
+			"Content-Type: multipart/related;\r\n"+
+			"\ttype=\"text/html\";\r\n"+
+			"\tboundary=\"%s\"\r\n\r\n", w.boundary)
+	return err
+}
+
+// WriteResource appends rawURL's response as one MIME part. Every part
+// is base64-encoded, text and binary alike; none is written as
+// quoted-printable.
+func (w *mhtmlWriter) WriteResource(rawURL string, headers http.Header, body []byte) (string, error) {
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if _, err := fmt.Fprintf(w.file,
+		"--%s\r\n"+
+			"Content-Type: %s\r\n"+
+			"Content-Transfer-Encoding: base64\r\n"+
+			"Content-Location: %s\r\n\r\n", w.boundary, contentType, rawURL); err != nil {
+		return "", err
+	}
+
+	if err := writeBase64Wrapped(w.file, body); err != nil {
+		return "", err
+	}
+	w.wrote = true
+
+	// MHTML keeps the original URL in Content-Location; browsers resolve
+	// references against it rather than requiring rewritten hrefs.
+	return rawURL, nil
+}
+
+func (w *mhtmlWriter) Close() error {
+	if w.wrote {
+		if _, err := fmt.Fprintf(w.file, "--%s--\r\n", w.boundary); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+// writeBase64Wrapped writes data as base64 wrapped at 76 characters per
+// line, as RFC 2045 (and every MHTML-producing browser) expects.
+func writeBase64Wrapped(f *os.File, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		if _, err := fmt.Fprintf(f, "%s\r\n", encoded[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// singleFileWriter inlines every resource directly into the page that
+// references it (CSS/JS as <style>/<script>, everything else as a data:
+// URI) so the crawl produces one self-contained .html file per page.
+// It keeps fetched resources in memory so that inlineOrRewrite, called by
+// processEntry while the page's DOM is walked, can inline them.
+type singleFileWriter struct {
+	mutex     sync.Mutex
+	resources map[string]fetchedResource
+}
+
+type fetchedResource struct {
+	contentType string
+	body        []byte
+}
+
+func newSingleFileWriter(outputDir string) (*singleFileWriter, error) {
+	return &singleFileWriter{resources: make(map[string]fetchedResource)}, nil
+}
+
+// WriteResource stores the resource in memory and returns a data: URI
+// that can be dropped straight into a src/href attribute. CSS and JS are
+// inlined as <style>/<script> by processEntry instead, which looks the
+// resource back up via InlineText.
+func (w *singleFileWriter) WriteResource(rawURL string, headers http.Header, body []byte) (string, error) {
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.mutex.Lock()
+	w.resources[rawURL] = fetchedResource{contentType: contentType, body: body}
+	w.mutex.Unlock()
+
+	mediaType := contentType
+	if idx := strings.Index(mediaType, ";"); idx != -1 {
+		mediaType = mediaType[:idx]
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(body), nil
+}
+
+// InlineText returns the previously fetched body for rawURL as a string,
+// for resource types (CSS, JS) that get inlined as text rather than as a
+// data: URI.
+func (w *singleFileWriter) InlineText(rawURL string) (string, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	res, ok := w.resources[rawURL]
+	return string(res.body), ok
+}
+
+// WritePage writes the fully-rewritten page to outputPath, the same
+// per-page path the "dir" format would have used. It is what actually
+// produces the self-contained .html file on disk: WriteResource only
+// caches a resource's bytes in memory so it can be inlined into the page
+// that references it, it never writes to outputDir itself.
+func (w *singleFileWriter) WritePage(outputPath string, html []byte) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, html, 0644)
+}
+
+func (w *singleFileWriter) Close() error { return nil }
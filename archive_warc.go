@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// warcWriter emits a single gzip-compressed WARC file, with a warcinfo
+// record up front followed by one "response" record per fetched URL.
+type warcWriter struct {
+	file *os.File
+	gz   *gzip.Writer
+}
+
+func newWarcWriter(outputDir string) (*warcWriter, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := outputDir + ".warc.gz"
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &warcWriter{file: f, gz: gzip.NewWriter(f)}
+	if err := w.writeWarcinfo(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *warcWriter) writeWarcinfo() error {
+	body := []byte("software: website-cloner\r\nformat: WARC File Format 1.0\r\n")
+
+	record := new(bytes.Buffer)
+	fmt.Fprint(record, "WARC/1.0\r\n")
+	fmt.Fprintf(record, "WARC-Type: warcinfo\r\n")
+	fmt.Fprintf(record, "WARC-Record-ID: <%s>\r\n", newWarcRecordID())
+	fmt.Fprintf(record, "WARC-Date: %s\r\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(record, "Content-Type: application/warc-fields\r\n")
+	fmt.Fprintf(record, "Content-Length: %d\r\n", len(body))
+	fmt.Fprint(record, "\r\n")
+	record.Write(body)
+	fmt.Fprint(record, "\r\n\r\n")
+
+	_, err := w.gz.Write(record.Bytes())
+	return err
+}
+
+// WriteResource appends a "response" record for rawURL whose HTTP block
+// is built from what it is handed: the status line is always written as
+// "HTTP/1.1 200 OK" (callers only pass 200 responses), followed by the
+// response headers sorted by name with Content-Length recomputed, and
+// body. For pages and stylesheets body is the rewritten copy, not the
+// bytes as fetched. The reference it returns is the original URL itself,
+// so resource references rewritten into a page are the absolute URLs the
+// records are keyed by.
+func (w *warcWriter) WriteResource(rawURL string, headers http.Header, body []byte) (string, error) {
+	httpBlock := new(bytes.Buffer)
+	fmt.Fprint(httpBlock, "HTTP/1.1 200 OK\r\n")
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		// Recomputed below from the actual body: the header we were
+		// handed reflects the length of the bytes as originally
+		// fetched, which is wrong once body has been rewritten (hrefs
+		// rewritten to point at local copies, CSS url() rewrites,
+		// etc.) and would leave replay tools unable to find the end
+		// of the HTTP block.
+		if strings.EqualFold(k, "Content-Length") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range headers[k] {
+			fmt.Fprintf(httpBlock, "%s: %s\r\n", k, v)
+		}
+	}
+	fmt.Fprintf(httpBlock, "Content-Length: %d\r\n", len(body))
+	fmt.Fprint(httpBlock, "\r\n")
+	httpBlock.Write(body)
+
+	record := new(bytes.Buffer)
+	fmt.Fprint(record, "WARC/1.0\r\n")
+	fmt.Fprint(record, "WARC-Type: response\r\n")
+	fmt.Fprintf(record, "WARC-Record-ID: <%s>\r\n", newWarcRecordID())
+	fmt.Fprintf(record, "WARC-Date: %s\r\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(record, "WARC-Target-URI: %s\r\n", rawURL)
+	fmt.Fprint(record, "Content-Type: application/http; msgtype=response\r\n")
+	fmt.Fprintf(record, "Content-Length: %d\r\n", httpBlock.Len())
+	fmt.Fprint(record, "\r\n")
+	record.Write(httpBlock.Bytes())
+	fmt.Fprint(record, "\r\n\r\n")
+
+	if _, err := w.gz.Write(record.Bytes()); err != nil {
+		return "", err
+	}
+	return rawURL, nil
+}
+
+func (w *warcWriter) Close() error {
+	if err := w.gz.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// newWarcRecordID returns a "urn:uuid:..." identifier for WARC-Record-ID,
+// generated with a standard random (v4) UUID.
+func newWarcRecordID() string {
+	return "urn:uuid:" + newUUIDv4()
+}
+
+// newUUIDv4 generates a random (version 4) UUID using crypto/rand.
+func newUUIDv4() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand failing is not something we can recover from
+		// usefully here; fall back to a fixed UUID (all zero apart from
+		// the version and variant bits) rather than crash.
+		return "00000000-0000-4000-8000-000000000000"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Extraction modes for the -extract flag.
+const (
+	ModeFull     = "full"
+	ModeReadable = "readable"
+	ModeText     = "text"
+	ModeMarkdown = "markdown"
+)
+
+// negativeClassPattern matches class/id names that are almost never part
+// of a page's main content.
+var negativeClassPattern = regexp.MustCompile(`(?i)comment|sidebar|footer|nav|share`)
+
+// multiBlankLines collapses runs of 3+ newlines down to a single blank
+// line, which cleanForExtraction's node removal tends to leave behind.
+var multiBlankLines = regexp.MustCompile(`\n{3,}`)
+
+// ExtractContent produces the content for the -extract mode: "full"
+// leaves the page alone (ok is false, since the raw HTML written by
+// processEntry already covers it), "text" and "readable" return plain
+// text, and "markdown" returns a Markdown rendering. readable and
+// markdown first narrow the document down to its main-content subtree
+// with selectMainContent, the way Mozilla's Readability does; text
+// extracts the whole <body>.
+func ExtractContent(doc *goquery.Document, mode string) (content, ext string, ok bool) {
+	switch mode {
+	case ModeText:
+		body := doc.Find("body").First()
+		cleanForExtraction(body)
+		return normalizeText(blockText(body)), "txt", true
+
+	case ModeReadable:
+		main := selectMainContent(doc)
+		cleanForExtraction(main)
+		return normalizeText(blockText(main)), "txt", true
+
+	case ModeMarkdown:
+		main := selectMainContent(doc)
+		cleanForExtraction(main)
+		return normalizeText(htmlToMarkdown(main)), "md", true
+
+	default:
+		return "", "", false
+	}
+}
+
+// selectMainContent scores every article/main/section/div in doc and
+// returns the highest-scoring one, falling back to <body> if nothing
+// scores above zero (e.g. a page with no real block structure).
+func selectMainContent(doc *goquery.Document) *goquery.Selection {
+	var best *goquery.Selection
+	bestScore := 0.0
+
+	doc.Find("article, main, section, div").Each(func(i int, s *goquery.Selection) {
+		score := scoreCandidate(s)
+		if score > bestScore {
+			best = s
+			bestScore = score
+		}
+	})
+
+	if best == nil {
+		return doc.Find("body").First()
+	}
+	return best
+}
+
+// scoreCandidate implements the readability heuristic: longer text minus
+// a link-density penalty, boosted for semantic tags and paragraph count,
+// and penalized for class/id names that usually mark chrome rather than
+// content.
+func scoreCandidate(s *goquery.Selection) float64 {
+	text := strings.TrimSpace(s.Text())
+	textLen := float64(len(text))
+	if textLen == 0 {
+		return 0
+	}
+
+	linkLen := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		linkLen += len(strings.TrimSpace(a.Text()))
+	})
+	linkDensity := float64(linkLen) / textLen
+
+	score := textLen * (1 - linkDensity)
+
+	switch goquery.NodeName(s) {
+	case "article":
+		score += 50
+	case "main":
+		score += 40
+	case "section":
+		score += 10
+	}
+	score += float64(s.Find("p").Length()) * 5
+
+	classAndID := strings.ToLower(attrOr(s, "class") + " " + attrOr(s, "id"))
+	if negativeClassPattern.MatchString(classAndID) {
+		score -= 50
+	}
+
+	return score
+}
+
+// attrOr returns s's attr, or "" if it isn't set.
+func attrOr(s *goquery.Selection, attr string) string {
+	v, _ := s.Attr(attr)
+	return v
+}
+
+// cleanForExtraction strips the parts of sel that a reader never wants:
+// scripts, styles, 1x1 tracking pixels, and nodes left with no text and
+// no image once everything else has been stripped.
+func cleanForExtraction(sel *goquery.Selection) {
+	sel.Find("script, style, noscript").Remove()
+
+	sel.Find("img").Each(func(i int, img *goquery.Selection) {
+		w, _ := img.Attr("width")
+		h, _ := img.Attr("height")
+		if (w == "1" || w == "0") && (h == "1" || h == "0") {
+			img.Remove()
+		}
+	})
+
+	sel.Find("*").Each(func(i int, el *goquery.Selection) {
+		if strings.TrimSpace(el.Text()) == "" && el.Find("img").Length() == 0 {
+			el.Remove()
+		}
+	})
+}
+
+// blockText renders sel's text the way a reader sees it on the page:
+// each block-level element (paragraph, heading, list item, ...) on its
+// own line, separated by a blank line, rather than goquery's .Text(),
+// which concatenates every descendant text node with no separation at
+// all and fuses adjacent paragraphs into one run-on word. Mirrors
+// htmlToMarkdown's block walk, minus the Markdown syntax.
+func blockText(sel *goquery.Selection) string {
+	var b strings.Builder
+	sel.Contents().Each(func(i int, child *goquery.Selection) {
+		writeBlockText(&b, child)
+	})
+	return b.String()
+}
+
+// writeBlockText renders one block-level node as plain text, recursing
+// into containers (div, section, ...) that aren't themselves a block
+// boundary so their block-level children still get separated.
+func writeBlockText(b *strings.Builder, s *goquery.Selection) {
+	node := s.Get(0)
+	if node == nil {
+		return
+	}
+
+	if node.Type == html.TextNode {
+		if text := strings.TrimSpace(s.Text()); text != "" {
+			fmt.Fprintf(b, "%s\n\n", text)
+		}
+		return
+	}
+
+	switch goquery.NodeName(s) {
+	case "h1", "h2", "h3", "h4", "h5", "h6", "p", "li", "blockquote", "pre", "td", "th":
+		if text := strings.TrimSpace(s.Text()); text != "" {
+			fmt.Fprintf(b, "%s\n\n", text)
+		}
+
+	case "br":
+		b.WriteString("\n")
+
+	default:
+		s.Contents().Each(func(i int, child *goquery.Selection) {
+			writeBlockText(b, child)
+		})
+	}
+}
+
+// normalizeText trims trailing whitespace from every line and collapses
+// runs of blank lines, so the .txt/.md output doesn't inherit the
+// original markup's indentation.
+func normalizeText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	collapsed := multiBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(collapsed)
+}
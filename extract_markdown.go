@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// htmlToMarkdown walks sel's children and renders a Markdown document
+// covering headings, paragraphs, lists, links, images, code, and
+// blockquotes. Anything else is rendered as plain inline text.
+func htmlToMarkdown(sel *goquery.Selection) string {
+	var b strings.Builder
+	sel.Contents().Each(func(i int, child *goquery.Selection) {
+		writeMarkdownBlock(&b, child)
+	})
+	return b.String()
+}
+
+// writeMarkdownBlock renders one block-level node (and, recursively, its
+// children) as Markdown, appended to b.
+func writeMarkdownBlock(b *strings.Builder, s *goquery.Selection) {
+	node := s.Get(0)
+	if node == nil {
+		return
+	}
+
+	if node.Type == html.TextNode {
+		if text := strings.TrimSpace(s.Text()); text != "" {
+			fmt.Fprintf(b, "%s\n\n", text)
+		}
+		return
+	}
+
+	switch goquery.NodeName(s) {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(goquery.NodeName(s)[1:])
+		fmt.Fprintf(b, "%s %s\n\n", strings.Repeat("#", level), inlineMarkdown(s))
+
+	case "p":
+		if text := inlineMarkdown(s); text != "" {
+			fmt.Fprintf(b, "%s\n\n", text)
+		}
+
+	case "blockquote":
+		for _, line := range strings.Split(strings.TrimSpace(inlineMarkdown(s)), "\n") {
+			fmt.Fprintf(b, "> %s\n", line)
+		}
+		b.WriteString("\n")
+
+	case "pre":
+		fmt.Fprintf(b, "```\n%s\n```\n\n", strings.TrimRight(s.Text(), "\n"))
+
+	case "ul":
+		writeMarkdownList(b, s, func(i int) string { return "- " })
+
+	case "ol":
+		writeMarkdownList(b, s, func(i int) string { return fmt.Sprintf("%d. ", i+1) })
+
+	case "img":
+		src, _ := s.Attr("src")
+		alt, _ := s.Attr("alt")
+		fmt.Fprintf(b, "![%s](%s)\n\n", alt, src)
+
+	case "hr":
+		b.WriteString("---\n\n")
+
+	case "br":
+		b.WriteString("\n")
+
+	default:
+		// Unknown container (div, section, header, ...): recurse into its
+		// children instead of losing their content.
+		s.Contents().Each(func(i int, child *goquery.Selection) {
+			writeMarkdownBlock(b, child)
+		})
+	}
+}
+
+// writeMarkdownList renders every top-level <li> of a <ul>/<ol> with the
+// marker bullet(index) returns, so ordered and unordered lists share one
+// implementation.
+func writeMarkdownList(b *strings.Builder, s *goquery.Selection, bullet func(i int) string) {
+	s.ChildrenFiltered("li").Each(func(i int, li *goquery.Selection) {
+		fmt.Fprintf(b, "%s%s\n", bullet(i), inlineMarkdown(li))
+	})
+	b.WriteString("\n")
+}
+
+// inlineMarkdown renders s's children as inline Markdown: links, bold,
+// italic, and inline code, with <br> as a line break and everything else
+// flattened to its text content.
+func inlineMarkdown(s *goquery.Selection) string {
+	var b strings.Builder
+	s.Contents().Each(func(i int, child *goquery.Selection) {
+		node := child.Get(0)
+		if node == nil {
+			return
+		}
+
+		if node.Type == html.TextNode {
+			b.WriteString(child.Text())
+			return
+		}
+
+		switch goquery.NodeName(child) {
+		case "a":
+			href, _ := child.Attr("href")
+			fmt.Fprintf(&b, "[%s](%s)", inlineMarkdown(child), href)
+		case "strong", "b":
+			fmt.Fprintf(&b, "**%s**", inlineMarkdown(child))
+		case "em", "i":
+			fmt.Fprintf(&b, "*%s*", inlineMarkdown(child))
+		case "code":
+			fmt.Fprintf(&b, "`%s`", child.Text())
+		case "br":
+			b.WriteString("\n")
+		default:
+			b.WriteString(inlineMarkdown(child))
+		}
+	})
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func mustParseHTML(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatalf("NewDocumentFromReader: %v", err)
+	}
+	return doc
+}
+
+func TestSelectMainContentPrefersArticleOverNav(t *testing.T) {
+	doc := mustParseHTML(t, `<html><body>
+		<nav class="nav"><a href="/">home</a><a href="/about">about</a><a href="/contact">contact</a></nav>
+		<article><p>This is the real article content, long enough that its text length comfortably beats the link-heavy navigation sitting next to it.</p></article>
+	</body></html>`)
+
+	main := selectMainContent(doc)
+	if !strings.Contains(main.Text(), "real article content") {
+		t.Fatalf("selectMainContent picked the wrong node: %q", main.Text())
+	}
+}
+
+func TestSelectMainContentFallsBackToBody(t *testing.T) {
+	doc := mustParseHTML(t, `<html><body>just some text, no block structure at all</body></html>`)
+
+	main := selectMainContent(doc)
+	if !strings.Contains(main.Text(), "just some text") {
+		t.Fatalf("selectMainContent fallback = %q, want body text", main.Text())
+	}
+}
+
+func TestBlockTextSeparatesParagraphs(t *testing.T) {
+	doc := mustParseHTML(t, `<html><body><p>First paragraph.</p><p>Second paragraph.</p></body></html>`)
+	body := doc.Find("body").First()
+
+	text := normalizeText(blockText(body))
+	if !strings.Contains(text, "First paragraph.\n\nSecond paragraph.") {
+		t.Fatalf("blockText fused adjacent paragraphs: %q", text)
+	}
+}
+
+func TestCleanForExtractionRemovesScriptsAndTrackingPixels(t *testing.T) {
+	doc := mustParseHTML(t, `<html><body>
+		<script>alert(1)</script>
+		<img src="/pixel.gif" width="1" height="1">
+		<p>Keep this.</p>
+	</body></html>`)
+	body := doc.Find("body").First()
+	cleanForExtraction(body)
+
+	if body.Find("script").Length() != 0 {
+		t.Fatalf("cleanForExtraction left a <script> in place")
+	}
+	if body.Find("img").Length() != 0 {
+		t.Fatalf("cleanForExtraction left a 1x1 tracking pixel in place")
+	}
+	if !strings.Contains(body.Text(), "Keep this.") {
+		t.Fatalf("cleanForExtraction removed real content: %q", body.Text())
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Politeness centralizes everything the crawler needs to avoid getting
+// itself blocked: a shared http.Client with timeouts, robots.txt
+// enforcement, a per-host rate limit, and a per-host concurrency cap.
+// processEntry and downloadResource both fetch through Politeness
+// instead of calling http.Get directly.
+type Politeness struct {
+	client         *http.Client
+	userAgent      string
+	acceptLanguage string
+	from           string
+	ignoreRobots   bool
+	rps            float64
+	delay          time.Duration
+	perHost        int
+
+	robotsMutex sync.Mutex
+	robots      map[string]*robotsRules
+
+	hostMutex sync.Mutex
+	hosts     map[string]*hostState
+}
+
+// hostState tracks the rate limit and in-flight concurrency for one host.
+type hostState struct {
+	mutex      sync.Mutex
+	nextSlot   time.Time // earliest time the next request to this host may start
+	crawlDelay time.Duration
+	sem        chan struct{}
+}
+
+// PolitenessConfig holds the flag-derived settings used to build a
+// Politeness layer.
+type PolitenessConfig struct {
+	UserAgent      string
+	AcceptLanguage string
+	From           string
+	IgnoreRobots   bool
+	RPS            float64
+	Delay          time.Duration
+	PerHost        int
+	Timeout        time.Duration
+}
+
+// NewPoliteness builds a Politeness layer with a shared http.Client that
+// has sane timeouts and a bounded redirect policy (the zero-value
+// http.Client used to use neither).
+func NewPoliteness(cfg PolitenessConfig) *Politeness {
+	return &Politeness{
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				return nil
+			},
+		},
+		userAgent:      cfg.UserAgent,
+		acceptLanguage: cfg.AcceptLanguage,
+		from:           cfg.From,
+		ignoreRobots:   cfg.IgnoreRobots,
+		rps:            cfg.RPS,
+		delay:          cfg.Delay,
+		perHost:        cfg.PerHost,
+		robots:         make(map[string]*robotsRules),
+		hosts:          make(map[string]*hostState),
+	}
+}
+
+// Get fetches rawURL, honoring robots.txt, the per-host rate limit, and
+// the per-host concurrency cap, with the configured User-Agent,
+// Accept-Language, and From headers set on every request.
+func (p *Politeness) Get(rawURL string) (*http.Response, error) {
+	return p.get(rawURL, time.Time{})
+}
+
+// GetConditional is Get, but adds an If-Modified-Since header so the
+// server can reply 304 Not Modified instead of resending a page we
+// already have (used for sitemap-seeded URLs, which carry a <lastmod>).
+func (p *Politeness) GetConditional(rawURL string, ifModifiedSince time.Time) (*http.Response, error) {
+	return p.get(rawURL, ifModifiedSince)
+}
+
+func (p *Politeness) get(rawURL string, ifModifiedSince time.Time) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	state := p.stateFor(u.Host)
+
+	if !p.ignoreRobots {
+		rules := p.robotsFor(u)
+		if rules != nil {
+			for _, prefix := range rules.disallow {
+				if prefix != "" && strings.HasPrefix(u.Path, prefix) {
+					return nil, fmt.Errorf("blocked by robots.txt: %s", rawURL)
+				}
+			}
+			if rules.crawlDelay > 0 {
+				state.mutex.Lock()
+				if rules.crawlDelay > state.crawlDelay {
+					state.crawlDelay = rules.crawlDelay
+				}
+				state.mutex.Unlock()
+			}
+		}
+	}
+
+	state.sem <- struct{}{}
+	defer func() { <-state.sem }()
+
+	p.throttle(state)
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+	if p.acceptLanguage != "" {
+		req.Header.Set("Accept-Language", p.acceptLanguage)
+	}
+	if p.from != "" {
+		req.Header.Set("From", p.from)
+	}
+	if !ifModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	return p.client.Do(req)
+}
+
+// stateFor returns (creating if necessary) the rate-limit/concurrency
+// state for host.
+func (p *Politeness) stateFor(host string) *hostState {
+	p.hostMutex.Lock()
+	defer p.hostMutex.Unlock()
+
+	state, ok := p.hosts[host]
+	if !ok {
+		perHost := p.perHost
+		if perHost < 1 {
+			perHost = 1
+		}
+		state = &hostState{sem: make(chan struct{}, perHost)}
+		p.hosts[host] = state
+	}
+	return state
+}
+
+// throttle blocks until enough time has passed since the last request to
+// this host, honoring -rps, -delay, and a robots.txt Crawl-delay
+// (whichever requires the longest wait).
+func (p *Politeness) throttle(state *hostState) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	interval := p.delay
+	if state.crawlDelay > interval {
+		interval = state.crawlDelay
+	}
+	if p.rps > 0 {
+		if rpsInterval := time.Duration(float64(time.Second) / p.rps); rpsInterval > interval {
+			interval = rpsInterval
+		}
+	}
+
+	now := time.Now()
+	if now.Before(state.nextSlot) {
+		time.Sleep(state.nextSlot.Sub(now))
+		now = state.nextSlot
+	}
+	state.nextSlot = now.Add(interval)
+}
+
+// robotsRules is the parsed subset of a robots.txt that applies to our
+// configured user agent: disallowed path prefixes and an optional
+// crawl-delay, plus the Sitemap directives (which apply regardless of
+// user agent).
+type robotsRules struct {
+	disallow   []string
+	crawlDelay time.Duration
+	sitemaps   []string
+}
+
+// SitemapsFor returns the Sitemap URLs robots.txt advertises for u's
+// host, or nil if there is no robots.txt or it names none.
+func (p *Politeness) SitemapsFor(u *url.URL) []string {
+	rules := p.robotsFor(u)
+	if rules == nil {
+		return nil
+	}
+	return rules.sitemaps
+}
+
+// robotsFor fetches and caches the robots.txt rules for u's host.
+func (p *Politeness) robotsFor(u *url.URL) *robotsRules {
+	p.robotsMutex.Lock()
+	if rules, ok := p.robots[u.Host]; ok {
+		p.robotsMutex.Unlock()
+		return rules
+	}
+	p.robotsMutex.Unlock()
+
+	robotsURL := fmt.Sprintf("%s://%s/robots.txt", u.Scheme, u.Host)
+	rules := fetchRobotsRules(p.client, p.userAgent, robotsURL)
+
+	p.robotsMutex.Lock()
+	p.robots[u.Host] = rules
+	p.robotsMutex.Unlock()
+
+	return rules
+}
+
+// fetchRobotsRules downloads and parses a robots.txt, returning nil if it
+// can't be fetched (which get treats as "everything is allowed").
+func fetchRobotsRules(client *http.Client, userAgent, robotsURL string) *robotsRules {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	return parseRobotsTxt(resp.Body, userAgent)
+}
+
+// parseRobotsTxt implements the common subset of the robots.txt format:
+// it collects Disallow and Crawl-delay directives from every group that
+// names our user agent (or, if none does, from the "*" groups), ignoring
+// Allow and every other record type. Consecutive User-agent lines form
+// one group.
+func parseRobotsTxt(r io.Reader, userAgent string) *robotsRules {
+	rules := &robotsRules{}
+
+	var inMatchingGroup, matchedSpecific, prevWasUserAgent bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			isStar := value == "*"
+			isUs := userAgent != "" && strings.Contains(strings.ToLower(userAgent), strings.ToLower(value))
+			if isUs {
+				if !matchedSpecific {
+					// The first group specific to us always wins over
+					// the wildcard group, even if it appeared earlier in
+					// the file: discard whatever "*" collected so far so
+					// its rules aren't unioned with this group's.
+					rules.disallow = nil
+					rules.crawlDelay = 0
+				}
+				matchedSpecific = true
+				inMatchingGroup = true
+			} else if isStar && !matchedSpecific {
+				inMatchingGroup = true
+			} else if !prevWasUserAgent {
+				inMatchingGroup = false
+			}
+		case "disallow":
+			if inMatchingGroup && value != "" {
+				rules.disallow = append(rules.disallow, value)
+			}
+		case "crawl-delay":
+			if inMatchingGroup {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+					rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			// Sitemap directives apply to every crawler, not just the
+			// group they happen to appear under.
+			if value != "" {
+				rules.sitemaps = append(rules.sitemaps, value)
+			}
+		}
+		prevWasUserAgent = field == "user-agent"
+	}
+
+	return rules
+}
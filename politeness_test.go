@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsTxtWildcardGroup(t *testing.T) {
+	txt := `User-agent: *
+Disallow: /private
+Crawl-delay: 2
+`
+	rules := parseRobotsTxt(strings.NewReader(txt), "website-cloner/1.0")
+	if len(rules.disallow) != 1 || rules.disallow[0] != "/private" {
+		t.Fatalf("disallow = %v, want [/private]", rules.disallow)
+	}
+	if rules.crawlDelay != 2*time.Second {
+		t.Fatalf("crawlDelay = %v, want 2s", rules.crawlDelay)
+	}
+}
+
+func TestParseRobotsTxtSpecificGroupReplacesWildcard(t *testing.T) {
+	txt := `User-agent: *
+Disallow: /private
+Crawl-delay: 5
+
+User-agent: website-cloner
+Disallow: /secret
+`
+	rules := parseRobotsTxt(strings.NewReader(txt), "website-cloner/1.0")
+	if len(rules.disallow) != 1 || rules.disallow[0] != "/secret" {
+		t.Fatalf("disallow = %v, want only [/secret] (wildcard group should not be unioned in)", rules.disallow)
+	}
+	if rules.crawlDelay != 0 {
+		t.Fatalf("crawlDelay = %v, want 0 (the specific group set none)", rules.crawlDelay)
+	}
+}
+
+func TestParseRobotsTxtSpecificGroupBeforeWildcard(t *testing.T) {
+	txt := `User-agent: website-cloner
+Disallow: /secret
+
+User-agent: *
+Disallow: /private
+`
+	rules := parseRobotsTxt(strings.NewReader(txt), "website-cloner/1.0")
+	if len(rules.disallow) != 1 || rules.disallow[0] != "/secret" {
+		t.Fatalf("disallow = %v, want only [/secret]", rules.disallow)
+	}
+}
+
+func TestParseRobotsTxtSitemapsApplyRegardlessOfGroup(t *testing.T) {
+	txt := `User-agent: someotherbot
+Disallow: /everything
+
+Sitemap: https://example.com/sitemap.xml
+`
+	rules := parseRobotsTxt(strings.NewReader(txt), "website-cloner/1.0")
+	if len(rules.sitemaps) != 1 || rules.sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Fatalf("sitemaps = %v, want [https://example.com/sitemap.xml]", rules.sitemaps)
+	}
+	if len(rules.disallow) != 0 {
+		t.Fatalf("disallow = %v, want none (that group isn't ours)", rules.disallow)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checkpointInterval is how many URLs are processed between fsyncs of
+// the on-disk queue files.
+const checkpointInterval = 20
+
+// QueueEntry is one unit of crawl work: a page URL, the depth it was
+// discovered at, and the page that linked to it.
+type QueueEntry struct {
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`
+	Referrer string `json:"referrer"`
+	LastMod  string `json:"lastmod,omitempty"` // RFC3339, set for sitemap-seeded entries
+}
+
+// CrawlQueue is a persistent, append-only crawl frontier. Every enqueued
+// URL is appended to a queue log, and every finished URL is appended to a
+// visited log; together they let a crawl interrupted with Ctrl-C be
+// resumed with -resume without redownloading pages it already fetched.
+type CrawlQueue struct {
+	mutex        sync.Mutex
+	queueFile    *os.File
+	visitedFile  *os.File
+	sitemapFile  *os.File
+	seen         map[string]bool // enqueued at least once this run
+	visitedAtRun map[string]bool // completed in a previous run (resume only)
+	sinceSync    int
+}
+
+// NewCrawlQueue opens (or creates) the queue, visited, and sitemap logs
+// under outputDir. When resume is false, all three are truncated so the
+// crawl starts clean; when true, they are appended to, so a crawl killed
+// mid-run (not just one that finished and left a final sitemap.xml
+// behind) still has every page it downloaded on record.
+func NewCrawlQueue(outputDir string, resume bool) (*CrawlQueue, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	queuePath := filepath.Join(outputDir, ".crawl-queue.jsonl")
+	visitedPath := filepath.Join(outputDir, ".crawl-visited.jsonl")
+	sitemapPath := filepath.Join(outputDir, ".crawl-sitemap.jsonl")
+
+	q := &CrawlQueue{
+		seen:         make(map[string]bool),
+		visitedAtRun: make(map[string]bool),
+	}
+
+	if resume {
+		if err := q.loadVisited(visitedPath); err != nil {
+			return nil, err
+		}
+	} else {
+		os.Remove(queuePath)
+		os.Remove(visitedPath)
+		os.Remove(sitemapPath)
+	}
+
+	queueFile, err := os.OpenFile(queuePath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	visitedFile, err := os.OpenFile(visitedPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		queueFile.Close()
+		return nil, err
+	}
+	sitemapFile, err := os.OpenFile(sitemapPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		queueFile.Close()
+		visitedFile.Close()
+		return nil, err
+	}
+
+	q.queueFile = queueFile
+	q.visitedFile = visitedFile
+	q.sitemapFile = sitemapFile
+	return q, nil
+}
+
+// loadVisited reads the visited log written by a previous run into
+// memory so Pending can skip entries that were already completed.
+func (q *CrawlQueue) loadVisited(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		url := scanner.Text()
+		if url != "" {
+			q.visitedAtRun[url] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// Pending reads the queue log written by a previous run and returns the
+// entries that were enqueued but never marked visited, in original
+// order, so a -resume run can pick up where it left off.
+func (q *CrawlQueue) Pending() ([]QueueEntry, error) {
+	if _, err := q.queueFile.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	defer q.queueFile.Seek(0, 2) // back to the end for subsequent appends
+
+	var pending []QueueEntry
+	scanner := bufio.NewScanner(q.queueFile)
+	for scanner.Scan() {
+		var entry QueueEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		q.seen[entry.URL] = true
+		if !q.visitedAtRun[entry.URL] {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, scanner.Err()
+}
+
+// MarkSeen records that url has been enqueued this run and reports
+// whether this is the first time, so callers can dedupe without
+// re-enqueuing the same URL from multiple referring pages.
+func (q *CrawlQueue) MarkSeen(url string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.seen[url] {
+		return false
+	}
+	q.seen[url] = true
+	return true
+}
+
+// Enqueue appends entry to the queue log.
+func (q *CrawlQueue) Enqueue(entry QueueEntry) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(q.queueFile, string(line))
+	return err
+}
+
+// MarkVisited appends url to the visited log and checkpoints (fsyncs)
+// both logs every checkpointInterval calls.
+func (q *CrawlQueue) MarkVisited(url string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, err := fmt.Fprintln(q.visitedFile, url); err != nil {
+		return err
+	}
+
+	q.sinceSync++
+	if q.sinceSync >= checkpointInterval {
+		q.sinceSync = 0
+		q.queueFile.Sync()
+		q.visitedFile.Sync()
+	}
+	return nil
+}
+
+// AppendSitemapEntry appends record to the sitemap log and fsyncs
+// immediately, so a page's sitemap entry survives a crash even though
+// MarkVisited's checkpointing only fsyncs the queue and visited logs
+// periodically: WriteSitemap reads this log back at the end of the run
+// (via SitemapEntries), and a dropped entry here means a page silently
+// missing from sitemap.xml forever, not just a slower resume.
+func (q *CrawlQueue) AppendSitemapEntry(record sitemapRecord) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(q.sitemapFile, string(line)); err != nil {
+		return err
+	}
+	return q.sitemapFile.Sync()
+}
+
+// SitemapEntries reads back every entry appended via AppendSitemapEntry,
+// across this run and (on -resume) every prior run against outputDir, so
+// sitemap.xml always lists every page ever successfully downloaded
+// rather than just the ones (re-)visited this run.
+func (q *CrawlQueue) SitemapEntries() ([]sitemapRecord, error) {
+	if _, err := q.sitemapFile.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	defer q.sitemapFile.Seek(0, 2) // back to the end for subsequent appends
+
+	var entries []sitemapRecord
+	scanner := bufio.NewScanner(q.sitemapFile)
+	for scanner.Scan() {
+		var entry sitemapRecord
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// jobQueue is an unbounded, in-memory FIFO shared by the worker pool. It
+// exists so that discovering new links never blocks a worker trying to
+// enqueue them (as a fixed-size channel could, if every worker is itself
+// blocked trying to push), while still letting at most -concurrency
+// workers pop and process entries at once.
+type jobQueue struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	items  []QueueEntry
+	closed bool
+}
+
+func newJobQueue() *jobQueue {
+	q := &jobQueue{}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *jobQueue) push(entry QueueEntry) {
+	q.mutex.Lock()
+	q.items = append(q.items, entry)
+	q.mutex.Unlock()
+	q.cond.Signal()
+}
+
+// pop blocks until an entry is available or the queue is closed, in
+// which case ok is false.
+func (q *jobQueue) pop() (entry QueueEntry, ok bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return QueueEntry{}, false
+	}
+
+	entry = q.items[0]
+	q.items = q.items[1:]
+	return entry, true
+}
+
+// close wakes every blocked pop once no more entries will ever be
+// pushed, so worker goroutines can exit.
+func (q *jobQueue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+	q.cond.Broadcast()
+}
+
+// Close closes the queue, visited, and sitemap logs, fsyncing the queue
+// and visited logs first (the sitemap log is fsynced on every append).
+func (q *CrawlQueue) Close() error {
+	q.queueFile.Sync()
+	q.visitedFile.Sync()
+
+	if err := q.queueFile.Close(); err != nil {
+		q.visitedFile.Close()
+		q.sitemapFile.Close()
+		return err
+	}
+	if err := q.visitedFile.Close(); err != nil {
+		q.sitemapFile.Close()
+		return err
+	}
+	return q.sitemapFile.Close()
+}
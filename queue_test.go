@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCrawlQueueEnqueuePendingMarkVisited(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := NewCrawlQueue(dir, false)
+	if err != nil {
+		t.Fatalf("NewCrawlQueue: %v", err)
+	}
+
+	entries := []QueueEntry{
+		{URL: "https://example.com/a", Depth: 0},
+		{URL: "https://example.com/b", Depth: 1, Referrer: "https://example.com/a"},
+	}
+	for _, e := range entries {
+		if !q.MarkSeen(e.URL) {
+			t.Fatalf("MarkSeen(%q) reported already-seen on first call", e.URL)
+		}
+		if err := q.Enqueue(e); err != nil {
+			t.Fatalf("Enqueue(%q): %v", e.URL, err)
+		}
+	}
+
+	if q.MarkSeen(entries[0].URL) {
+		t.Fatalf("MarkSeen(%q) reported unseen on second call", entries[0].URL)
+	}
+
+	if err := q.MarkVisited(entries[0].URL); err != nil {
+		t.Fatalf("MarkVisited: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopen with resume=true, as -resume does after a crawl was
+	// interrupted: only the entry never marked visited should come back.
+	resumed, err := NewCrawlQueue(dir, true)
+	if err != nil {
+		t.Fatalf("NewCrawlQueue (resume): %v", err)
+	}
+	defer resumed.Close()
+
+	pending, err := resumed.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].URL != entries[1].URL {
+		t.Fatalf("Pending after resume = %v, want only %q", pending, entries[1].URL)
+	}
+}
+
+func TestCrawlQueueNonResumeTruncatesPriorLogs(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := NewCrawlQueue(dir, false)
+	if err != nil {
+		t.Fatalf("NewCrawlQueue: %v", err)
+	}
+	if err := q.Enqueue(QueueEntry{URL: "https://example.com/a"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := q.MarkVisited("https://example.com/a"); err != nil {
+		t.Fatalf("MarkVisited: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	fresh, err := NewCrawlQueue(dir, false)
+	if err != nil {
+		t.Fatalf("NewCrawlQueue (fresh): %v", err)
+	}
+	defer fresh.Close()
+
+	pending, err := fresh.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("Pending after non-resume restart = %v, want none", pending)
+	}
+}
+
+func TestCrawlQueueSitemapEntriesSurviveAcrossRuns(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := NewCrawlQueue(dir, false)
+	if err != nil {
+		t.Fatalf("NewCrawlQueue: %v", err)
+	}
+	if err := q.AppendSitemapEntry(sitemapRecord{RelPath: "index.html"}); err != nil {
+		t.Fatalf("AppendSitemapEntry: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A later run, resumed after this one was killed before writing
+	// sitemap.xml, must still see the entry recorded above.
+	resumed, err := NewCrawlQueue(dir, true)
+	if err != nil {
+		t.Fatalf("NewCrawlQueue (resume): %v", err)
+	}
+	defer resumed.Close()
+
+	if err := resumed.AppendSitemapEntry(sitemapRecord{RelPath: "about.html"}); err != nil {
+		t.Fatalf("AppendSitemapEntry: %v", err)
+	}
+
+	entries, err := resumed.SitemapEntries()
+	if err != nil {
+		t.Fatalf("SitemapEntries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].RelPath != "index.html" || entries[1].RelPath != "about.html" {
+		t.Fatalf("SitemapEntries = %v, want [index.html about.html]", entries)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ResourceMap assigns every fetched URL a stable, collision-free local
+// path under <OutputDir>/resources/<type>/..., keyed by the full
+// resolved URL (including query string). This replaces the old
+// filepath.Base(href) rewriting, which silently overwrote any two
+// resources that shared a basename (e.g. "foo.js?v=1" and "foo.js?v=2")
+// and broke links on pages nested in subdirectories.
+type ResourceMap struct {
+	mutex sync.Mutex
+	paths map[string]string // absolute URL -> path relative to OutputDir
+}
+
+func NewResourceMap() *ResourceMap {
+	return &ResourceMap{paths: make(map[string]string)}
+}
+
+// PathFor returns the OutputDir-relative path to use for rawURL,
+// creating and caching one on first use. resourceType only matters the
+// first time a given URL is seen; later callers (e.g. the goroutine that
+// actually downloads the bytes) get back the same path regardless of
+// what resourceType they pass, so the href written into the page and the
+// file written to disk always agree.
+func (m *ResourceMap) PathFor(rawURL, resourceType string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if p, ok := m.paths[rawURL]; ok {
+		return p
+	}
+
+	p := buildResourcePath(rawURL, resourceType)
+	m.paths[rawURL] = p
+	return p
+}
+
+var invalidFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// buildResourcePath derives a readable-but-unique filename for rawURL: an
+// 8 hex character hash of the full URL (so the query string is part of
+// the identity) followed by the URL's basename.
+func buildResourcePath(rawURL, resourceType string) string {
+	basename := "resource"
+	if idx := strings.IndexAny(rawURL, "?#"); idx != -1 {
+		basename = path.Base(rawURL[:idx])
+	} else {
+		basename = path.Base(rawURL)
+	}
+	if basename == "" || basename == "." || basename == "/" {
+		basename = "resource"
+	}
+	basename = invalidFilenameChars.ReplaceAllString(basename, "_")
+
+	sum := sha1.Sum([]byte(rawURL))
+	hash := hex.EncodeToString(sum[:4])
+
+	return path.Join("resources", resourceType, hash+"-"+basename)
+}
+
+// RelativePath computes the path to resourcePath (itself relative to
+// outputDir) as seen from pageOutputPath, e.g. a page written to
+// "<outputDir>/blog/post/index.html" gets "../../resources/..." instead
+// of the bare "resources/..." that only worked for top-level pages.
+func RelativePath(outputDir, pageOutputPath, resourcePath string) string {
+	rel, err := filepath.Rel(filepath.Dir(pageOutputPath), filepath.Join(outputDir, resourcePath))
+	if err != nil {
+		return resourcePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// cssURLPattern matches url(...) references inside a stylesheet, with or
+// without surrounding quotes.
+var cssURLPattern = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)`)
+
+// rewriteCSSURLs hands every url(...) reference in css to fetch, which
+// resolves and downloads it, and replaces the reference with the localRef
+// fetch returns. data: URLs, and references for which fetch reports
+// !ok, are left as they are. It returns the rewritten CSS text.
+func rewriteCSSURLs(css string, fetch func(ref string) (localRef string, ok bool)) string {
+	return cssURLPattern.ReplaceAllStringFunc(css, func(match string) string {
+		groups := cssURLPattern.FindStringSubmatch(match)
+		ref := strings.TrimSpace(groups[2])
+		if ref == "" || strings.HasPrefix(ref, "data:") {
+			return match
+		}
+
+		localRef, ok := fetch(ref)
+		if !ok {
+			return match
+		}
+		return fmt.Sprintf("url(%s)", localRef)
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestResourceMapPathForIsStableAndCollisionFree(t *testing.T) {
+	m := NewResourceMap()
+
+	a := m.PathFor("https://example.com/foo.js?v=1", JS)
+	b := m.PathFor("https://example.com/foo.js?v=2", JS)
+	if a == b {
+		t.Fatalf("two distinct URLs with the same basename collided: %q", a)
+	}
+
+	again := m.PathFor("https://example.com/foo.js?v=1", JS)
+	if again != a {
+		t.Fatalf("PathFor wasn't stable across calls: got %q, then %q", a, again)
+	}
+}
+
+func TestResourceMapPathForIgnoresResourceTypeAfterFirstUse(t *testing.T) {
+	m := NewResourceMap()
+
+	first := m.PathFor("https://example.com/thing", JS)
+	second := m.PathFor("https://example.com/thing", IMG)
+	if first != second {
+		t.Fatalf("second PathFor call with a different resourceType returned a different path: %q vs %q", first, second)
+	}
+}
+
+func TestBuildResourcePathSanitizesBasename(t *testing.T) {
+	p := buildResourcePath("https://example.com/a%20b*.png", IMG)
+	_, basename, _ := strings.Cut(path.Base(p), "-")
+	if invalidFilenameChars.MatchString(basename) {
+		t.Fatalf("buildResourcePath left invalid filename characters in basename %q (full path %q)", basename, p)
+	}
+}
+
+func TestBuildResourcePathFallsBackForEmptyBasename(t *testing.T) {
+	p := buildResourcePath("https://example.com/", HTML)
+	_, basename, _ := strings.Cut(path.Base(p), "-")
+	if basename == "" || basename == "." {
+		t.Fatalf("buildResourcePath produced an empty basename for a root URL: %q", p)
+	}
+}
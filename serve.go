@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadScript is injected into every served text/html response just
+// before </body>. It opens an SSE connection to /_reload and reloads the
+// page the moment the server says the output directory changed.
+const reloadScript = `<script>new EventSource("/_reload").onmessage = () => location.reload();</script>`
+
+// liveReloadServer serves -output over HTTP and pushes a reload event to
+// every connected browser whenever a file under root changes on disk,
+// which StartServe's fsnotify watcher drives.
+type liveReloadServer struct {
+	root string
+
+	mutex   sync.Mutex
+	clients map[chan string]bool
+}
+
+func newLiveReloadServer(root string) *liveReloadServer {
+	return &liveReloadServer{root: root, clients: make(map[chan string]bool)}
+}
+
+// broadcast notifies every connected /_reload client.
+func (s *liveReloadServer) broadcast() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for ch := range s.clients {
+		select {
+		case ch <- "reload":
+		default:
+			// Client isn't keeping up; it'll reload on its next event anyway.
+		}
+	}
+}
+
+// handleReload implements the /_reload SSE endpoint.
+func (s *liveReloadServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := make(chan string, 1)
+	s.mutex.Lock()
+	s.clients[ch] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, ch)
+		s.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", msg)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleFile serves files out of root, injecting reloadScript into any
+// text/html response so the page can listen for /_reload events.
+func (s *liveReloadServer) handleFile(w http.ResponseWriter, r *http.Request) {
+	requestPath := r.URL.Path
+	if strings.HasSuffix(requestPath, "/") {
+		requestPath += "index.html"
+	}
+
+	localPath := filepath.Join(s.root, filepath.Clean("/"+requestPath))
+	if !strings.HasSuffix(localPath, ".html") {
+		http.ServeFile(w, r, localPath)
+		return
+	}
+
+	body, err := os.ReadFile(localPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	injected := injectReloadScript(string(body))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, injected)
+}
+
+// injectReloadScript inserts reloadScript just before </body>, or appends
+// it to the end of the document if there's no closing body tag to find.
+func injectReloadScript(html string) string {
+	if idx := strings.LastIndex(strings.ToLower(html), "</body>"); idx != -1 {
+		return html[:idx] + reloadScript + html[idx:]
+	}
+	return html + reloadScript
+}
+
+// StartServe serves root over HTTP at addr, watching it with fsnotify and
+// pushing a reload event to every connected browser whenever a file
+// changes, until the process is killed.
+func StartServe(root, addr string) error {
+	server := newLiveReloadServer(root)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	if err := watchRecursive(watcher, root); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					server.broadcast()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("serve: watcher error: %v", err)
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_reload", server.handleReload)
+	mux.HandleFunc("/", server.handleFile)
+
+	fmt.Printf("Serving %s on http://%s with live reload\n", root, addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// watchRecursive adds root and every directory beneath it to watcher.
+func watchRecursive(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
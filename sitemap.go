@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxURLsPerSitemap is the sitemaps.org limit on <url> entries in a
+// single sitemap file; WriteSitemap splits larger output into an index.
+const maxURLsPerSitemap = 50000
+
+// sitemapRecord is one page written to disk during a dir-format crawl,
+// remembered so runCrawl can emit a sitemap.xml for the clone afterward.
+type sitemapRecord struct {
+	RelPath string
+	LastMod string // W3C datetime, empty if the response had no Last-Modified
+}
+
+// recordSitemapEntry notes that relPath was just written to disk with
+// the given Last-Modified header value (RFC1123, as HTTP sends it). The
+// entry is appended to the crawl queue's own sitemap log rather than kept
+// only in memory, so a crawl killed mid-run (not just one stopped
+// cleanly with -resume's predecessor run finishing) still has every page
+// downloaded so far on record when -resume writes the final sitemap.xml.
+func (c *Config) recordSitemapEntry(relPath, httpLastMod string) {
+	record := sitemapRecord{RelPath: filepath.ToSlash(relPath)}
+	if t, err := http.ParseTime(httpLastMod); err == nil {
+		record.LastMod = t.UTC().Format(time.RFC3339)
+	}
+
+	if err := c.Queue.AppendSitemapEntry(record); err != nil {
+		fmt.Printf("Failed to record sitemap entry for %s: %v\n", relPath, err)
+	}
+}
+
+// discoverSitemapSeeds looks for a sitemap for baseURL's site -- first
+// via robots.txt's Sitemap directives, falling back to the conventional
+// /sitemap.xml and /sitemap_index.xml locations -- and returns every
+// <loc> it names as a QueueEntry ready to seed the crawl.
+func discoverSitemapSeeds(p *Politeness, baseURL *url.URL) []QueueEntry {
+	locations := p.SitemapsFor(baseURL)
+	if len(locations) == 0 {
+		root := fmt.Sprintf("%s://%s", baseURL.Scheme, baseURL.Host)
+		locations = []string{root + "/sitemap.xml", root + "/sitemap_index.xml"}
+	}
+
+	seen := make(map[string]bool)
+	var seeds []QueueEntry
+	for _, loc := range locations {
+		fetchSitemapSeeds(p, loc, seen, &seeds)
+	}
+	return seeds
+}
+
+// fetchSitemapSeeds fetches and parses the sitemap (or sitemap index) at
+// rawURL, appending every URL entry it finds to seeds. Sitemap index
+// entries are fetched and expanded in turn; seen dedupes across nested
+// sitemaps so the same <loc> is never queued twice.
+func fetchSitemapSeeds(p *Politeness, rawURL string, seen map[string]bool, seeds *[]QueueEntry) {
+	resp, err := p.Get(rawURL)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if strings.HasSuffix(rawURL, ".gz") || resp.Header.Get("Content-Encoding") == "gzip" {
+		body, err = gunzip(body)
+		if err != nil {
+			return
+		}
+	}
+
+	urls, nestedSitemaps, err := parseSitemapXML(body)
+	if err != nil {
+		return
+	}
+
+	for _, u := range urls {
+		if u.Loc == "" || seen[u.Loc] {
+			continue
+		}
+		seen[u.Loc] = true
+		*seeds = append(*seeds, QueueEntry{URL: u.Loc, Depth: 0, Referrer: rawURL, LastMod: normalizeLastMod(u.LastMod)})
+	}
+
+	for _, nested := range nestedSitemaps {
+		if seen[nested] {
+			continue
+		}
+		seen[nested] = true
+		fetchSitemapSeeds(p, nested, seen, seeds)
+	}
+}
+
+// gunzip decompresses a gzip-encoded sitemap body.
+func gunzip(body []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+type sitemapURLEntry struct {
+	Loc     string
+	LastMod string
+}
+
+// parseSitemapXML parses either sitemap format: a <urlset> of <url>
+// entries, or a <sitemapindex> of <sitemap> entries pointing at more
+// sitemaps. It distinguishes the two by peeking at the root element.
+func parseSitemapXML(body []byte) (urls []sitemapURLEntry, nestedSitemaps []string, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, tokErr := decoder.Token()
+		if tokErr != nil {
+			return nil, nil, tokErr
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "urlset":
+			var v struct {
+				URLs []struct {
+					Loc     string `xml:"loc"`
+					LastMod string `xml:"lastmod"`
+				} `xml:"url"`
+			}
+			if err := xml.Unmarshal(body, &v); err != nil {
+				return nil, nil, err
+			}
+			for _, u := range v.URLs {
+				urls = append(urls, sitemapURLEntry{Loc: u.Loc, LastMod: u.LastMod})
+			}
+			return urls, nil, nil
+
+		case "sitemapindex":
+			var v struct {
+				Sitemaps []struct {
+					Loc string `xml:"loc"`
+				} `xml:"sitemap"`
+			}
+			if err := xml.Unmarshal(body, &v); err != nil {
+				return nil, nil, err
+			}
+			for _, s := range v.Sitemaps {
+				nestedSitemaps = append(nestedSitemaps, s.Loc)
+			}
+			return nil, nestedSitemaps, nil
+
+		default:
+			return nil, nil, fmt.Errorf("not a sitemap: unexpected root element %q", start.Name.Local)
+		}
+	}
+}
+
+// normalizeLastMod converts a sitemap <lastmod> (W3C datetime, which
+// allows several precisions) to RFC3339 so QueueEntry.LastMod always
+// parses the same way; entries that don't parse become "", so
+// processEntry fetches them with a plain, unconditional GET.
+func normalizeLastMod(lastMod string) string {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, lastMod); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// WriteSitemap emits <outputDir>/sitemap.xml listing every page recorded
+// via recordSitemapEntry, splitting into <outputDir>/sitemap-N.xml files
+// plus a sitemap.xml index when there are more than maxURLsPerSitemap.
+func WriteSitemap(outputDir string, entries []sitemapRecord) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if len(entries) <= maxURLsPerSitemap {
+		return writeURLSet(filepath.Join(outputDir, "sitemap.xml"), entries)
+	}
+
+	var files []string
+	for i := 0; i < len(entries); i += maxURLsPerSitemap {
+		end := i + maxURLsPerSitemap
+		if end > len(entries) {
+			end = len(entries)
+		}
+		name := fmt.Sprintf("sitemap-%d.xml", i/maxURLsPerSitemap+1)
+		if err := writeURLSet(filepath.Join(outputDir, name), entries[i:end]); err != nil {
+			return err
+		}
+		files = append(files, name)
+	}
+	return writeSitemapIndex(filepath.Join(outputDir, "sitemap.xml"), files)
+}
+
+func writeURLSet(path string, entries []sitemapRecord) error {
+	type xmlURL struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+	doc := struct {
+		XMLName xml.Name `xml:"urlset"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		URLs    []xmlURL `xml:"url"`
+	}{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	for _, e := range entries {
+		doc.URLs = append(doc.URLs, xmlURL{Loc: e.RelPath, LastMod: e.LastMod})
+	}
+
+	return writeXMLFile(path, doc)
+}
+
+func writeSitemapIndex(path string, files []string) error {
+	type xmlSitemap struct {
+		Loc string `xml:"loc"`
+	}
+	doc := struct {
+		XMLName  xml.Name     `xml:"sitemapindex"`
+		Xmlns    string       `xml:"xmlns,attr"`
+		Sitemaps []xmlSitemap `xml:"sitemap"`
+	}{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	for _, f := range files {
+		doc.Sitemaps = append(doc.Sitemaps, xmlSitemap{Loc: f})
+	}
+
+	return writeXMLFile(path, doc)
+}
+
+func writeXMLFile(path string, doc interface{}) error {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(xml.Header)
+	out.Write(body)
+	out.WriteString("\n")
+
+	return os.WriteFile(path, out.Bytes(), 0644)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSitemapXMLURLSet(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><lastmod>2024-01-02</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`)
+
+	urls, nested, err := parseSitemapXML(body)
+	if err != nil {
+		t.Fatalf("parseSitemapXML: %v", err)
+	}
+	if len(nested) != 0 {
+		t.Fatalf("nested = %v, want none for a urlset", nested)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("urls = %v, want 2 entries", urls)
+	}
+	if urls[0].Loc != "https://example.com/" || urls[0].LastMod != "2024-01-02" {
+		t.Fatalf("urls[0] = %+v, want Loc=https://example.com/ LastMod=2024-01-02", urls[0])
+	}
+	if urls[1].Loc != "https://example.com/about" || urls[1].LastMod != "" {
+		t.Fatalf("urls[1] = %+v, want Loc=https://example.com/about LastMod=\"\"", urls[1])
+	}
+}
+
+func TestParseSitemapXMLIndex(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-1.xml</loc></sitemap>
+  <sitemap><loc>https://example.com/sitemap-2.xml</loc></sitemap>
+</sitemapindex>`)
+
+	urls, nested, err := parseSitemapXML(body)
+	if err != nil {
+		t.Fatalf("parseSitemapXML: %v", err)
+	}
+	if len(urls) != 0 {
+		t.Fatalf("urls = %v, want none for a sitemapindex", urls)
+	}
+	if len(nested) != 2 || nested[0] != "https://example.com/sitemap-1.xml" || nested[1] != "https://example.com/sitemap-2.xml" {
+		t.Fatalf("nested = %v, want the two sitemap locs", nested)
+	}
+}
+
+func TestParseSitemapXMLRejectsUnknownRoot(t *testing.T) {
+	if _, _, err := parseSitemapXML([]byte(`<rss><channel></channel></rss>`)); err == nil {
+		t.Fatal("parseSitemapXML accepted a non-sitemap document")
+	}
+}
+
+func TestNormalizeLastMod(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"2024-01-02T15:04:05Z", "2024-01-02T15:04:05Z"},
+		{"2024-01-02", "2024-01-02T00:00:00Z"},
+		{"not-a-date", ""},
+	}
+	for _, c := range cases {
+		if got := normalizeLastMod(c.in); got != c.want {
+			t.Errorf("normalizeLastMod(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestWriteSitemapSplitsAcrossIndexWhenOverLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	entries := make([]sitemapRecord, maxURLsPerSitemap+1)
+	for i := range entries {
+		entries[i] = sitemapRecord{RelPath: "page.html"}
+	}
+
+	if err := WriteSitemap(dir, entries); err != nil {
+		t.Fatalf("WriteSitemap: %v", err)
+	}
+
+	if _, err := os.ReadFile(dir + "/sitemap.xml"); err != nil {
+		t.Fatalf("sitemap.xml (index) missing: %v", err)
+	}
+	if _, err := os.ReadFile(dir + "/sitemap-1.xml"); err != nil {
+		t.Fatalf("sitemap-1.xml missing: %v", err)
+	}
+	if _, err := os.ReadFile(dir + "/sitemap-2.xml"); err != nil {
+		t.Fatalf("sitemap-2.xml missing: %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -26,12 +27,33 @@ const (
 // Configuration for website cloning
 type Config struct {
 	URL          string
+	BaseHost     string // host of URL, for the same-host check in processEntry
 	OutputDir    string
 	MaxDepth     int
 	ResourcesDir string
-	VisitedURLs  map[string]bool
-	mutex        sync.Mutex
-	wg           sync.WaitGroup
+	Concurrency  int
+	Format       string
+	Extract      string
+	Writer       ArchiveWriter
+	Resources    *ResourceMap
+	Queue        *CrawlQueue
+	Politeness   *Politeness
+	jobs         *jobQueue
+	taskWG       sync.WaitGroup // outstanding page jobs, queued or in-flight
+	wg           sync.WaitGroup // outstanding resource downloads
+}
+
+// CloneOptions holds everything a single crawl run needs, independent of
+// how it was invoked (a one-shot clone, or one tick of -watch).
+type CloneOptions struct {
+	URL         string
+	OutputDir   string
+	MaxDepth    int
+	Format      string
+	Extract     string
+	Concurrency int
+	Resume      bool
+	Politeness  PolitenessConfig
 }
 
 func main() {
@@ -39,6 +61,22 @@ func main() {
 	urlFlag := flag.String("url", "", "URL of the website to clone")
 	outputFlag := flag.String("output", "cloned-site", "Output directory")
 	depthFlag := flag.Int("depth", 1, "Maximum depth for crawling links")
+	formatFlag := flag.String("format", "dir", "Output format: dir, warc, mhtml, or single-file")
+	extractFlag := flag.String("extract", "full", "Content extraction mode: full, readable, text, or markdown (dir format only)")
+	concurrencyFlag := flag.Int("concurrency", 8, "Maximum number of pages to crawl at once")
+	resumeFlag := flag.Bool("resume", false, "Resume a previously interrupted crawl from -output's queue")
+	ignoreRobotsFlag := flag.Bool("ignore-robots", false, "Don't fetch or honor robots.txt")
+	rpsFlag := flag.Float64("rps", 2, "Maximum requests per second per host")
+	perHostFlag := flag.Int("per-host", 2, "Maximum in-flight requests per host")
+	delayFlag := flag.Duration("delay", 0, "Minimum delay between requests to the same host")
+	timeoutFlag := flag.Duration("timeout", 30*time.Second, "HTTP request timeout")
+	userAgentFlag := flag.String("user-agent", "website-cloner/1.0", "User-Agent header to send")
+	acceptLanguageFlag := flag.String("accept-language", "", "Accept-Language header to send")
+	fromFlag := flag.String("from", "", "From header to send (e.g. an operator contact email)")
+	serveFlag := flag.Bool("serve", false, "Serve -output with live reload after cloning")
+	serveAddrFlag := flag.String("serve-addr", "localhost:8000", "Address for -serve to listen on")
+	watchFlag := flag.Duration("watch", 0, "Re-crawl -watch-urls (or -url) on this interval and update -output")
+	watchURLsFlag := flag.String("watch-urls", "", "Comma-separated seed URLs to re-crawl for -watch (defaults to -url)")
 	flag.Parse()
 
 	// Check if URL is provided
@@ -51,86 +89,257 @@ func main() {
 		}
 	}
 
-	// Create configuration
+	opts := CloneOptions{
+		URL:         *urlFlag,
+		OutputDir:   *outputFlag,
+		MaxDepth:    *depthFlag,
+		Format:      *formatFlag,
+		Extract:     *extractFlag,
+		Concurrency: *concurrencyFlag,
+		Resume:      *resumeFlag,
+		Politeness: PolitenessConfig{
+			UserAgent:      *userAgentFlag,
+			AcceptLanguage: *acceptLanguageFlag,
+			From:           *fromFlag,
+			IgnoreRobots:   *ignoreRobotsFlag,
+			RPS:            *rpsFlag,
+			Delay:          *delayFlag,
+			PerHost:        *perHostFlag,
+			Timeout:        *timeoutFlag,
+		},
+	}
+
+	if err := runCrawl(opts); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *watchFlag > 0 {
+		seeds := []string{opts.URL}
+		if *watchURLsFlag != "" {
+			seeds = strings.Split(*watchURLsFlag, ",")
+		}
+		if *serveFlag {
+			go watchLoop(*watchFlag, seeds, opts)
+		} else {
+			watchLoop(*watchFlag, seeds, opts)
+		}
+	}
+
+	if *serveFlag {
+		if err := StartServe(opts.OutputDir, *serveAddrFlag); err != nil {
+			log.Fatalf("serve: %v", err)
+		}
+	}
+}
+
+// watchLoop re-crawls every seed URL on the given interval, running until
+// the process exits. Each tick starts from a clean Config (Resume is
+// always forced off) so the re-crawl actually revisits every page.
+func watchLoop(interval time.Duration, seeds []string, base CloneOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, seed := range seeds {
+			opts := base
+			opts.URL = strings.TrimSpace(seed)
+			opts.Resume = false
+
+			fmt.Printf("watch: re-crawling %s\n", opts.URL)
+			if err := runCrawl(opts); err != nil {
+				fmt.Printf("watch: failed to re-crawl %s: %v\n", opts.URL, err)
+			}
+		}
+	}
+}
+
+// runCrawl performs one full clone of opts.URL into opts.OutputDir.
+func runCrawl(opts CloneOptions) error {
+	if opts.Concurrency < 1 {
+		return fmt.Errorf("invalid -concurrency %d: must be at least 1", opts.Concurrency)
+	}
+
 	config := &Config{
-		URL:          *urlFlag,
-		OutputDir:    *outputFlag,
-		MaxDepth:     *depthFlag,
+		URL:          opts.URL,
+		OutputDir:    opts.OutputDir,
+		MaxDepth:     opts.MaxDepth,
 		ResourcesDir: "resources",
-		VisitedURLs:  make(map[string]bool),
+		Concurrency:  opts.Concurrency,
+		Format:       opts.Format,
+		Extract:      opts.Extract,
+		Resources:    NewResourceMap(),
+		jobs:         newJobQueue(),
+		Politeness:   NewPoliteness(opts.Politeness),
 	}
 
 	// Parse the base URL
 	baseURL, err := url.Parse(config.URL)
 	if err != nil {
-		log.Fatalf("Invalid URL: %v", err)
+		return fmt.Errorf("invalid URL: %w", err)
 	}
+	config.BaseHost = baseURL.Host
 
-	// Create output directories
-	err = os.MkdirAll(config.OutputDir, 0755)
+	// Create the archive writer for the requested output format. Only
+	// the "dir" format writes loose files, so it is the only one that
+	// needs its directory tree created up front.
+	config.Writer, err = NewArchiveWriter(opts.Format, config.OutputDir, config.Resources)
 	if err != nil {
-		log.Fatalf("Failed to create output directory: %v", err)
+		return fmt.Errorf("invalid -format: %w", err)
 	}
 
-	resourcesPath := filepath.Join(config.OutputDir, config.ResourcesDir)
-	err = os.MkdirAll(filepath.Join(resourcesPath, CSS), 0755)
-	if err != nil {
-		log.Fatalf("Failed to create CSS directory: %v", err)
+	switch config.Extract {
+	case "", ModeFull, ModeReadable, ModeText, ModeMarkdown:
+	default:
+		return fmt.Errorf("invalid -extract %q (want full, readable, text, or markdown)", config.Extract)
 	}
 
-	err = os.MkdirAll(filepath.Join(resourcesPath, JS), 0755)
-	if err != nil {
-		log.Fatalf("Failed to create JS directory: %v", err)
+	if opts.Format == "" || opts.Format == "dir" {
+		if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
 	}
 
-	err = os.MkdirAll(filepath.Join(resourcesPath, IMG), 0755)
+	config.Queue, err = NewCrawlQueue(config.OutputDir, opts.Resume)
 	if err != nil {
-		log.Fatalf("Failed to create IMG directory: %v", err)
+		return fmt.Errorf("failed to open crawl queue: %w", err)
 	}
 
-	fmt.Printf("Starting to clone %s into %s\n", config.URL, config.OutputDir)
+	fmt.Printf("Starting to clone %s into %s (format: %s, concurrency: %d)\n", config.URL, config.OutputDir, opts.Format, config.Concurrency)
+
+	// Start the bounded worker pool. Unlike the old "one goroutine per
+	// link" fan-out, at most Concurrency pages are ever being crawled at
+	// the same time, regardless of how many links a site has.
+	var workers sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				entry, ok := config.jobs.pop()
+				if !ok {
+					return
+				}
+				config.processEntry(entry)
+				config.taskWG.Done()
+			}
+		}()
+	}
 
-	// Start cloning process
-	config.cloneURL(baseURL, 0)
+	if opts.Resume {
+		pending, err := config.Queue.Pending()
+		if err != nil {
+			return fmt.Errorf("failed to read crawl queue: %w", err)
+		}
+		fmt.Printf("Resuming crawl with %d pending URL(s)\n", len(pending))
+		for _, entry := range pending {
+			config.taskWG.Add(1)
+			config.jobs.push(entry)
+		}
+	} else {
+		config.enqueue(QueueEntry{URL: baseURL.String(), Depth: 0})
+		for _, seed := range discoverSitemapSeeds(config.Politeness, baseURL) {
+			config.enqueue(seed)
+		}
+	}
 
-	// Wait for all goroutines to finish
+	// Wait for every queued and in-flight page to finish, then for any
+	// still-running resource downloads.
+	config.taskWG.Wait()
+	config.jobs.close()
+	workers.Wait()
 	config.wg.Wait()
 
+	if opts.Format == "" || opts.Format == "dir" {
+		// Read back from the queue's sitemap log (not an in-memory slice)
+		// so a crawl that was killed and resumed still lists every page
+		// ever successfully downloaded, not just the ones this run
+		// (re-)visited.
+		sitemapEntries, err := config.Queue.SitemapEntries()
+		if err != nil {
+			return fmt.Errorf("failed to read sitemap entries: %w", err)
+		}
+		if err := WriteSitemap(config.OutputDir, sitemapEntries); err != nil {
+			return fmt.Errorf("failed to write sitemap.xml: %w", err)
+		}
+	}
+
+	if err := config.Queue.Close(); err != nil {
+		return fmt.Errorf("failed to close crawl queue: %w", err)
+	}
+
+	if err := config.Writer.Close(); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
 	fmt.Println("Website cloning completed successfully!")
+	return nil
+}
+
+// enqueue records entry as seen, persists it to the crawl queue, and
+// hands it to the worker pool. It is a no-op if entry.URL was already
+// enqueued this run, so a URL linked from many pages is only crawled
+// once.
+func (c *Config) enqueue(entry QueueEntry) {
+	if !c.Queue.MarkSeen(entry.URL) {
+		return
+	}
+	if err := c.Queue.Enqueue(entry); err != nil {
+		fmt.Printf("Failed to persist queue entry for %s: %v\n", entry.URL, err)
+	}
+
+	c.taskWG.Add(1)
+	c.jobs.push(entry)
 }
 
-// cloneURL downloads a URL and processes its content
-func (c *Config) cloneURL(pageURL *url.URL, depth int) {
+// processEntry downloads a queued URL and processes its content.
+func (c *Config) processEntry(entry QueueEntry) {
+	pageURL, err := url.Parse(entry.URL)
+	if err != nil {
+		fmt.Printf("Failed to parse queued URL %s: %v\n", entry.URL, err)
+		return
+	}
+	depth := entry.Depth
+
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Printf("Recovered from panic while processing %s: %v\n", pageURL.String(), r)
 		}
+		if err := c.Queue.MarkVisited(entry.URL); err != nil {
+			fmt.Printf("Failed to checkpoint %s: %v\n", entry.URL, err)
+		}
 	}()
 
 	urlStr := pageURL.String()
 
-	// Check if already visited
-	c.mutex.Lock()
-	if c.VisitedURLs[urlStr] {
-		c.mutex.Unlock()
+	// Only process URLs from the same host. Comparing hosts rather than
+	// substring-matching the seed URL means seeding with a non-root page
+	// (e.g. -url https://example.com/blog/post) still follows every
+	// same-host link instead of only ones whose URL happens to contain
+	// "/blog/post".
+	if pageURL.Host != "" && pageURL.Host != c.BaseHost {
 		return
 	}
-	c.VisitedURLs[urlStr] = true
-	c.mutex.Unlock()
 
-	// Only process URLs from the same host
-	if pageURL.Host != "" && !strings.Contains(urlStr, strings.TrimPrefix(strings.TrimPrefix(c.URL, "http://"), "https://")) {
-		return
+	// Get the webpage content. Sitemap-seeded entries carry a <lastmod>,
+	// which we send as If-Modified-Since so an unchanged page costs the
+	// site a 304 instead of a full re-download.
+	var resp *http.Response
+	if lastMod, perr := time.Parse(time.RFC3339, entry.LastMod); perr == nil {
+		resp, err = c.Politeness.GetConditional(urlStr, lastMod)
+	} else {
+		resp, err = c.Politeness.Get(urlStr)
 	}
-
-	// Get the webpage content
-	resp, err := http.Get(urlStr)
 	if err != nil {
 		fmt.Printf("Failed to fetch %s: %v\n", urlStr, err)
 		return
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		fmt.Printf("Not modified, skipping: %s\n", urlStr)
+		return
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		fmt.Printf("Got non-200 status code for %s: %d\n", urlStr, resp.StatusCode)
 		return
@@ -157,11 +366,17 @@ func (c *Config) cloneURL(pageURL *url.URL, depth int) {
 
 	outputPath := filepath.Join(c.OutputDir, filename)
 
-	// Create necessary directories
-	err = os.MkdirAll(filepath.Dir(outputPath), 0755)
-	if err != nil {
-		fmt.Printf("Failed to create directory for %s: %v\n", outputPath, err)
-		return
+	// Archive formats (warc, mhtml, single-file) write everything through
+	// c.Writer into a single archive file instead of the loose directory
+	// tree, so there is no per-page directory to create.
+	archiveFormat := c.Format != "" && c.Format != "dir"
+
+	if !archiveFormat {
+		err = os.MkdirAll(filepath.Dir(outputPath), 0755)
+		if err != nil {
+			fmt.Printf("Failed to create directory for %s: %v\n", outputPath, err)
+			return
+		}
 	}
 
 	// Parse HTML document
@@ -171,49 +386,92 @@ func (c *Config) cloneURL(pageURL *url.URL, depth int) {
 		return
 	}
 
+	// Archive formats (warc, mhtml, single-file) need the resource's
+	// fetched bytes before the page itself is serialized, so they are
+	// downloaded synchronously here. The plain "dir" format keeps the
+	// original fire-and-forget goroutine behavior for speed.
+
 	// Process CSS links
 	doc.Find("link[rel='stylesheet']").Each(func(i int, s *goquery.Selection) {
 		if href, exists := s.Attr("href"); exists {
-			c.wg.Add(1)
-			go func(href string) {
-				defer c.wg.Done()
-				c.downloadResource(pageURL, href, CSS)
-			}(href)
-
-			// Update href attribute to point to local resource
-			localPath := filepath.Join(c.ResourcesDir, CSS, filepath.Base(href))
-			s.SetAttr("href", strings.ReplaceAll(localPath, "\\", "/"))
+			if archiveFormat {
+				c.inlineOrRewrite(s, pageURL, href, "href", CSS)
+				return
+			}
+			s.SetAttr("href", c.resolveAndQueue(pageURL, outputPath, href, CSS))
 		}
 	})
 
 	// Process JavaScript files
 	doc.Find("script[src]").Each(func(i int, s *goquery.Selection) {
 		if src, exists := s.Attr("src"); exists {
-			c.wg.Add(1)
-			go func(src string) {
-				defer c.wg.Done()
-				c.downloadResource(pageURL, src, JS)
-			}(src)
-
-			// Update src attribute to point to local resource
-			localPath := filepath.Join(c.ResourcesDir, JS, filepath.Base(src))
-			s.SetAttr("src", strings.ReplaceAll(localPath, "\\", "/"))
+			if archiveFormat {
+				c.inlineOrRewrite(s, pageURL, src, "src", JS)
+				return
+			}
+			s.SetAttr("src", c.resolveAndQueue(pageURL, outputPath, src, JS))
 		}
 	})
 
 	// Process images
 	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
 		if src, exists := s.Attr("src"); exists {
-			c.wg.Add(1)
-			go func(src string) {
-				defer c.wg.Done()
-				c.downloadResource(pageURL, src, IMG)
-			}(src)
-
-			// Update src attribute to point to local resource
-			localPath := filepath.Join(c.ResourcesDir, IMG, filepath.Base(src))
-			s.SetAttr("src", strings.ReplaceAll(localPath, "\\", "/"))
+			if archiveFormat {
+				c.inlineOrRewrite(s, pageURL, src, "src", IMG)
+				return
+			}
+			s.SetAttr("src", c.resolveAndQueue(pageURL, outputPath, src, IMG))
+		}
+	})
+
+	// Process responsive image candidates: img/source srcset lists, and
+	// <source> elements inside <picture>/<video>/<audio>.
+	doc.Find("img[srcset], source[srcset]").Each(func(i int, s *goquery.Selection) {
+		if srcset, exists := s.Attr("srcset"); exists {
+			s.SetAttr("srcset", c.rewriteSrcset(pageURL, outputPath, archiveFormat, srcset, IMG))
+		}
+	})
+
+	doc.Find("source[src]").Each(func(i int, s *goquery.Selection) {
+		if src, exists := s.Attr("src"); exists {
+			if archiveFormat {
+				ref, _ := c.resolveAndFetch(pageURL, src, IMG)
+				s.SetAttr("src", ref)
+				return
+			}
+			s.SetAttr("src", c.resolveAndQueue(pageURL, outputPath, src, IMG))
+		}
+	})
+
+	// Process preload/icon/manifest links, which point at a resource but
+	// aren't <link rel="stylesheet">.
+	doc.Find(`link[rel="preload"], link[rel="icon"], link[rel="shortcut icon"], link[rel="apple-touch-icon"], link[rel="manifest"]`).Each(func(i int, s *goquery.Selection) {
+		if href, exists := s.Attr("href"); exists {
+			resourceType := linkResourceType(s)
+			if archiveFormat {
+				ref, _ := c.resolveAndFetch(pageURL, href, resourceType)
+				s.SetAttr("href", ref)
+				return
+			}
+			s.SetAttr("href", c.resolveAndQueue(pageURL, outputPath, href, resourceType))
+		}
+	})
+
+	// Process inline style="background:url(...)" references.
+	doc.Find("[style]").Each(func(i int, s *goquery.Selection) {
+		style, exists := s.Attr("style")
+		if !exists || !strings.Contains(style, "url(") {
+			return
 		}
+
+		rewritten := rewriteCSSURLs(style, func(ref string) (string, bool) {
+			if archiveFormat {
+				localRef, _ := c.resolveAndFetch(pageURL, ref, IMG)
+				return localRef, true
+			}
+			return c.resolveAndQueue(pageURL, outputPath, ref, IMG), true
+		})
+		s.SetAttr("style", rewritten)
 	})
 
 	// Save the modified HTML document
@@ -223,13 +481,43 @@ func (c *Config) cloneURL(pageURL *url.URL, depth int) {
 		return
 	}
 
-	err = os.WriteFile(outputPath, []byte(modifiedHTML), 0644)
-	if err != nil {
-		fmt.Printf("Failed to write HTML file for %s: %v\n", urlStr, err)
-		return
+	if archiveFormat {
+		if sfw, ok := c.Writer.(*singleFileWriter); ok {
+			// single-file has no archive file to append a record to; the
+			// page itself is the output, written to the same per-page
+			// path "dir" mode would have used.
+			if err := sfw.WritePage(outputPath, []byte(modifiedHTML)); err != nil {
+				fmt.Printf("Failed to write page %s: %v\n", urlStr, err)
+				return
+			}
+		} else if _, err := c.Writer.WriteResource(urlStr, resp.Header, []byte(modifiedHTML)); err != nil {
+			fmt.Printf("Failed to archive page %s: %v\n", urlStr, err)
+			return
+		}
+	} else {
+		err = os.WriteFile(outputPath, []byte(modifiedHTML), 0644)
+		if err != nil {
+			fmt.Printf("Failed to write HTML file for %s: %v\n", urlStr, err)
+			return
+		}
+
+		if relPath, err := filepath.Rel(c.OutputDir, outputPath); err == nil {
+			c.recordSitemapEntry(relPath, resp.Header.Get("Last-Modified"))
+		}
+
+		if content, ext, ok := ExtractContent(doc, c.Extract); ok {
+			extractedPath := extractedOutputPath(outputPath, ext)
+			if err := os.WriteFile(extractedPath, []byte(content), 0644); err != nil {
+				fmt.Printf("Failed to write extracted content for %s: %v\n", urlStr, err)
+			}
+		}
 	}
 
-	fmt.Printf("Downloaded: %s -> %s\n", urlStr, outputPath)
+	if archiveFormat {
+		fmt.Printf("Archived: %s\n", urlStr)
+	} else {
+		fmt.Printf("Downloaded: %s -> %s\n", urlStr, outputPath)
+	}
 
 	// Process links if depth allows
 	if depth < c.MaxDepth {
@@ -250,73 +538,212 @@ func (c *Config) cloneURL(pageURL *url.URL, depth int) {
 
 				// Only follow links to the same host
 				if resolvedURL.Host == pageURL.Host {
-					c.wg.Add(1)
-					go func(resolvedURL *url.URL, depth int) {
-						defer c.wg.Done()
-						c.cloneURL(resolvedURL, depth+1)
-					}(resolvedURL, depth)
+					c.enqueue(QueueEntry{
+						URL:      resolvedURL.String(),
+						Depth:    depth + 1,
+						Referrer: urlStr,
+					})
 				}
 			}
 		})
 	}
 }
 
-// downloadResource downloads a resource file (CSS, JS, IMG) and saves it locally
-func (c *Config) downloadResource(baseURL *url.URL, resourceURL string, resourceType string) {
-	// Resolve the resource URL
-	resolvedURL, err := url.Parse(resourceURL)
+// resolveAndQueue is the "dir" format's resource rewriting path: it
+// computes the ResourceMap path for ref up front (relative to the
+// document at outputPath) so the attribute can be rewritten immediately,
+// then kicks off the actual download in the background, mirroring the
+// original fire-and-forget goroutine behavior.
+func (c *Config) resolveAndQueue(pageURL *url.URL, outputPath, ref, resourceType string) string {
+	parsed, err := url.Parse(ref)
 	if err != nil {
-		fmt.Printf("Failed to parse resource URL %s: %v\n", resourceURL, err)
-		return
+		return ref
 	}
 
-	// Handle relative URLs
-	absoluteURL := baseURL.ResolveReference(resolvedURL)
+	absolute := pageURL.ResolveReference(parsed)
+	if strings.HasPrefix(absolute.String(), "data:") {
+		return ref
+	}
 
-	// Skip data: URLs
-	if strings.HasPrefix(absoluteURL.String(), "data:") {
-		return
+	localPath := c.Resources.PathFor(absolute.String(), resourceType)
+	rel := RelativePath(c.OutputDir, outputPath, localPath)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.downloadResource(pageURL, ref, resourceType)
+	}()
+
+	return rel
+}
+
+// resolveAndFetch is the archive formats' resource rewriting path: since
+// warc/mhtml/single-file all need the fetched bytes before the page can
+// be serialized, it downloads synchronously and returns whatever ref the
+// ArchiveWriter says should replace the original attribute, along with
+// the resolved absolute URL (used to look resources back up for inline
+// single-file rewriting).
+func (c *Config) resolveAndFetch(pageURL *url.URL, ref, resourceType string) (localRef, absoluteURL string) {
+	resp, body, absolute, err := c.fetchResource(pageURL, ref)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return ref, ""
+	}
+	if resp == nil {
+		// data: URL, left untouched.
+		return ref, absolute.String()
+	}
+
+	if resourceType == CSS {
+		body = c.processCSS(absolute, "", true, body)
+	}
+
+	out, err := c.Writer.WriteResource(absolute.String(), resp.Header, body)
+	if err != nil {
+		fmt.Printf("Failed to write resource %s: %v\n", absolute.String(), err)
+		return ref, absolute.String()
+	}
+	return out, absolute.String()
+}
+
+// rewriteSrcset rewrites the URL portion of each candidate in a srcset
+// attribute ("url descriptor, url descriptor, ..."), leaving the
+// width/density descriptor untouched.
+func (c *Config) rewriteSrcset(pageURL *url.URL, outputPath string, archiveFormat bool, srcset, resourceType string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(strings.TrimSpace(candidate))
+		if len(fields) == 0 {
+			continue
+		}
+
+		if archiveFormat {
+			fields[0], _ = c.resolveAndFetch(pageURL, fields[0], resourceType)
+		} else {
+			fields[0] = c.resolveAndQueue(pageURL, outputPath, fields[0], resourceType)
+		}
+		candidates[i] = " " + strings.Join(fields, " ")
 	}
+	return strings.Join(candidates, ",")
+}
+
+// linkResourceType picks the resource bucket for a <link> tag based on
+// its "as" attribute (used by rel="preload"), defaulting to IMG for
+// icons and manifests.
+func linkResourceType(s *goquery.Selection) string {
+	switch as, _ := s.Attr("as"); as {
+	case "style":
+		return CSS
+	case "script":
+		return JS
+	default:
+		return IMG
+	}
+}
+
+// extractedOutputPath swaps outputPath's extension for ext, so
+// output/blog/post.html with ext "md" becomes output/blog/post.md.
+func extractedOutputPath(outputPath, ext string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "." + ext
+}
+
+// processCSS rewrites every url(...) reference in a downloaded
+// stylesheet so that fonts, background images, etc. it references are
+// themselves fetched and rewritten. referrerOutputPath is the CSS file's
+// own location on disk and is only used in "dir" mode, where rewritten
+// references must be relative to it rather than to OutputDir.
+func (c *Config) processCSS(cssURL *url.URL, referrerOutputPath string, archiveFormat bool, body []byte) []byte {
+	rewritten := rewriteCSSURLs(string(body), func(ref string) (string, bool) {
+		if archiveFormat {
+			localRef, _ := c.resolveAndFetch(cssURL, ref, IMG)
+			return localRef, true
+		}
+		return c.resolveAndQueue(cssURL, referrerOutputPath, ref, IMG), true
+	})
+	return []byte(rewritten)
+}
 
-	// Download the resource
-	resp, err := http.Get(absoluteURL.String())
+// downloadResource downloads a resource file (CSS, JS, IMG) and saves it
+// via the configured ArchiveWriter. It is only used by the "dir" format;
+// archive formats fetch synchronously via resolveAndFetch instead.
+func (c *Config) downloadResource(baseURL *url.URL, resourceURL string, resourceType string) {
+	resp, body, absoluteURL, err := c.fetchResource(baseURL, resourceURL)
 	if err != nil {
-		fmt.Printf("Failed to fetch resource %s: %v\n", absoluteURL.String(), err)
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if resp == nil {
+		// Skipped, e.g. a data: URL.
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Got non-200 status code for resource %s: %d\n", absoluteURL.String(), resp.StatusCode)
+	if resourceType == CSS {
+		cssOutputPath := filepath.Join(c.OutputDir, c.Resources.PathFor(absoluteURL.String(), CSS))
+		body = c.processCSS(absoluteURL, cssOutputPath, false, body)
+	}
+
+	if _, err := c.Writer.WriteResource(absoluteURL.String(), resp.Header, body); err != nil {
+		fmt.Printf("Failed to write resource %s: %v\n", absoluteURL.String(), err)
 		return
 	}
 
-	// Read the resource data
-	data, err := io.ReadAll(resp.Body)
+	fmt.Printf("Downloaded resource: %s\n", absoluteURL.String())
+}
+
+// fetchResource resolves resourceURL against baseURL and downloads it,
+// returning the response, its body, and the resolved URL. A nil response
+// with a nil error means the URL was intentionally skipped (e.g. data:).
+func (c *Config) fetchResource(baseURL *url.URL, resourceURL string) (*http.Response, []byte, *url.URL, error) {
+	resolvedURL, err := url.Parse(resourceURL)
 	if err != nil {
-		fmt.Printf("Failed to read resource data for %s: %v\n", absoluteURL.String(), err)
-		return
+		return nil, nil, nil, fmt.Errorf("failed to parse resource URL %s: %w", resourceURL, err)
 	}
 
-	// Determine the output filename
-	filename := filepath.Base(absoluteURL.Path)
-	if filename == "" || filename == "." {
-		filename = fmt.Sprintf("resource_%d", len(c.VisitedURLs))
+	absoluteURL := baseURL.ResolveReference(resolvedURL)
+
+	if strings.HasPrefix(absoluteURL.String(), "data:") {
+		return nil, nil, absoluteURL, nil
 	}
 
-	// Ensure the filename is valid
-	filename = strings.ReplaceAll(filename, "?", "_")
-	filename = strings.ReplaceAll(filename, "&", "_")
-	filename = strings.ReplaceAll(filename, "=", "_")
+	resp, err := c.Politeness.Get(absoluteURL.String())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to fetch resource %s: %w", absoluteURL.String(), err)
+	}
+	defer resp.Body.Close()
 
-	outputPath := filepath.Join(c.OutputDir, c.ResourcesDir, resourceType, filename)
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, nil, fmt.Errorf("got non-200 status code for resource %s: %d", absoluteURL.String(), resp.StatusCode)
+	}
 
-	// Write the resource file
-	err = os.WriteFile(outputPath, data, 0644)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("Failed to write resource file %s: %v\n", outputPath, err)
-		return
+		return nil, nil, nil, fmt.Errorf("failed to read resource data for %s: %w", absoluteURL.String(), err)
+	}
+
+	return resp, body, absoluteURL, nil
+}
+
+// inlineOrRewrite fetches a CSS/JS/IMG resource synchronously and either
+// inlines it into the page (single-file) or rewrites its attribute to
+// point at the ArchiveWriter's reference (warc, mhtml).
+func (c *Config) inlineOrRewrite(s *goquery.Selection, pageURL *url.URL, resourceURL, attr, resourceType string) {
+	ref, absoluteURL := c.resolveAndFetch(pageURL, resourceURL, resourceType)
+
+	if sfw, ok := c.Writer.(*singleFileWriter); ok {
+		switch resourceType {
+		case CSS:
+			if css, found := sfw.InlineText(absoluteURL); found {
+				s.ReplaceWithHtml("<style>" + css + "</style>")
+				return
+			}
+		case JS:
+			if js, found := sfw.InlineText(absoluteURL); found {
+				s.RemoveAttr("src")
+				s.SetText(js)
+				return
+			}
+		}
 	}
 
-	fmt.Printf("Downloaded resource: %s -> %s\n", absoluteURL.String(), outputPath)
+	s.SetAttr(attr, ref)
 }